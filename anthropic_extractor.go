@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// anthropicExtractor calls the Messages API, attaching the PDF as a
+// document content block. The Messages API has no structured-output mode,
+// so when a schema is supplied it's appended to the prompt as a contract
+// instead of passed as a request parameter.
+type anthropicExtractor struct {
+	apiKey string
+	model  string
+}
+
+type anthropicSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+type anthropicContentBlock struct {
+	Type   string           `json:"type"`
+	Text   string           `json:"text,omitempty"`
+	Source *anthropicSource `json:"source,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string                  `json:"role"`
+	Content []anthropicContentBlock `json:"content"`
+}
+
+type anthropicRequest struct {
+	Model     string             `json:"model"`
+	MaxTokens int                `json:"max_tokens"`
+	Messages  []anthropicMessage `json:"messages"`
+}
+
+type anthropicResponse struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+}
+
+func (e *anthropicExtractor) Extract(ctx context.Context, prompt string, pdf []byte, schema *openAPISchema) (json.RawMessage, error) {
+	if schema != nil {
+		schemaJSON, err := json.Marshal(schema)
+		if err != nil {
+			return nil, fmt.Errorf("marshaling schema: %w", err)
+		}
+		prompt += "\n\nRespond with JSON matching this schema exactly:\n" + string(schemaJSON)
+	}
+
+	blocks := []anthropicContentBlock{}
+	if len(pdf) > 0 {
+		blocks = append(blocks, anthropicContentBlock{
+			Type: "document",
+			Source: &anthropicSource{
+				Type:      "base64",
+				MediaType: "application/pdf",
+				Data:      base64.StdEncoding.EncodeToString(pdf),
+			},
+		})
+	}
+	blocks = append(blocks, anthropicContentBlock{Type: "text", Text: prompt})
+
+	req := anthropicRequest{
+		Model:     e.model,
+		MaxTokens: 4096,
+		Messages:  []anthropicMessage{{Role: "user", Content: blocks}},
+	}
+
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://api.anthropic.com/v1/messages", bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-api-key", e.apiKey)
+	httpReq.Header.Set("anthropic-version", "2023-06-01")
+
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("anthropic request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("anthropic error %d: %s", resp.StatusCode, string(body))
+	}
+
+	var ar anthropicResponse
+	if err := json.NewDecoder(resp.Body).Decode(&ar); err != nil {
+		return nil, fmt.Errorf("decoding anthropic response: %w", err)
+	}
+
+	text := ""
+	for _, block := range ar.Content {
+		text += block.Text
+	}
+	return decodeExtractorResponse(text), nil
+}
+
+func (e *anthropicExtractor) Model() string {
+	return e.model
+}
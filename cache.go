@@ -0,0 +1,210 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultCacheMaxEntries = 1000
+	defaultCacheTTL        = 24 * time.Hour
+)
+
+// Cache stores extraction results keyed by a content hash, with a TTL.
+// The in-memory LRU is the default; Redis is selected via CACHE_BACKEND.
+type Cache interface {
+	Get(ctx context.Context, key string) (json.RawMessage, bool)
+	Set(ctx context.Context, key string, value json.RawMessage, ttl time.Duration)
+	Delete(ctx context.Context, key string)
+}
+
+// cacheKey computes sha256(fileBytes) || sha256(normalizedSchema) || model
+// || chunkPages || mergeStrategy so identical uploads with identical
+// extraction contracts hit the cache regardless of request order, and
+// requests that differ only in how a large PDF is chunked and merged never
+// share an entry.
+func cacheKey(fileBytes []byte, schema *openAPISchema, model string, chunkPages int, mergeStrategy string) string {
+	fileHash := sha256.Sum256(fileBytes)
+	schemaBytes, _ := json.Marshal(schema) // nil schema marshals to "null", still deterministic
+	schemaHash := sha256.Sum256(schemaBytes)
+	return fmt.Sprintf("%s:%s:%s:%d:%s", hex.EncodeToString(fileHash[:]), hex.EncodeToString(schemaHash[:]), model, chunkPages, mergeStrategy)
+}
+
+// selectCacheBackend picks the cache implementation from CACHE_BACKEND,
+// falling back to the in-memory LRU if Redis isn't configured or reachable.
+func selectCacheBackend() Cache {
+	if os.Getenv("CACHE_BACKEND") != "redis" {
+		return newLRUCache(defaultCacheMaxEntries)
+	}
+	rc, err := newRedisCache(os.Getenv("REDIS_URL"))
+	if err != nil {
+		log.Printf("redis cache unavailable, falling back to in-memory: %v", err)
+		return newLRUCache(defaultCacheMaxEntries)
+	}
+	return rc
+}
+
+type cacheEntry struct {
+	key       string
+	value     json.RawMessage
+	expiresAt time.Time
+}
+
+// lruCache is the default in-memory Cache backend.
+type lruCache struct {
+	mu         sync.Mutex
+	items      map[string]*list.Element
+	order      *list.List
+	maxEntries int
+}
+
+func newLRUCache(maxEntries int) *lruCache {
+	return &lruCache{items: make(map[string]*list.Element), order: list.New(), maxEntries: maxEntries}
+}
+
+func (c *lruCache) Get(_ context.Context, key string) (json.RawMessage, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*cacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.order.Remove(el)
+		delete(c.items, key)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return entry.value, true
+}
+
+func (c *lruCache) Set(_ context.Context, key string, value json.RawMessage, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		entry := el.Value.(*cacheEntry)
+		entry.value = value
+		entry.expiresAt = time.Now().Add(ttl)
+		c.order.MoveToFront(el)
+		return
+	}
+
+	el := c.order.PushFront(&cacheEntry{key: key, value: value, expiresAt: time.Now().Add(ttl)})
+	c.items[key] = el
+
+	if c.maxEntries > 0 && c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		if oldest != nil {
+			c.order.Remove(oldest)
+			delete(c.items, oldest.Value.(*cacheEntry).key)
+		}
+	}
+}
+
+func (c *lruCache) Delete(_ context.Context, key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.order.Remove(el)
+		delete(c.items, key)
+	}
+}
+
+// redisCache is the Cache backend used when CACHE_BACKEND=redis.
+type redisCache struct {
+	client *redis.Client
+}
+
+func newRedisCache(url string) (*redisCache, error) {
+	if url == "" {
+		return nil, fmt.Errorf("REDIS_URL not set")
+	}
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("parsing REDIS_URL: %w", err)
+	}
+	client := redis.NewClient(opts)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("pinging redis: %w", err)
+	}
+	return &redisCache{client: client}, nil
+}
+
+func (c *redisCache) Get(ctx context.Context, key string) (json.RawMessage, bool) {
+	val, err := c.client.Get(ctx, key).Bytes()
+	if err != nil {
+		return nil, false
+	}
+	return json.RawMessage(val), true
+}
+
+func (c *redisCache) Set(ctx context.Context, key string, value json.RawMessage, ttl time.Duration) {
+	if err := c.client.Set(ctx, key, []byte(value), ttl).Err(); err != nil {
+		log.Println("redis cache set error:", err)
+	}
+}
+
+func (c *redisCache) Delete(ctx context.Context, key string) {
+	if err := c.client.Del(ctx, key).Err(); err != nil {
+		log.Println("redis cache delete error:", err)
+	}
+}
+
+// cacheTTL reads CACHE_TTL_SECONDS, defaulting to 24h.
+func cacheTTL() time.Duration {
+	raw := os.Getenv("CACHE_TTL_SECONDS")
+	if raw == "" {
+		return defaultCacheTTL
+	}
+	seconds, err := strconv.Atoi(raw)
+	if err != nil || seconds <= 0 {
+		return defaultCacheTTL
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// newCacheDeleteHandler returns the DELETE /api/cache/:hash admin endpoint,
+// guarded by a shared secret in the X-Admin-Secret header.
+func newCacheDeleteHandler(cache Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		allowCORS(w)
+		if r.Method != http.MethodDelete {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		secret := os.Getenv("CACHE_ADMIN_SECRET")
+		if secret == "" || r.Header.Get("X-Admin-Secret") != secret {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		hash := strings.TrimPrefix(r.URL.Path, "/api/cache/")
+		if hash == "" {
+			http.Error(w, "missing cache hash", http.StatusBadRequest)
+			return
+		}
+
+		cache.Delete(r.Context(), hash)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+const (
+	defaultChunkSizeThreshold = 15 << 20 // 15MB
+	defaultChunkMaxPages      = 20
+	chunkWorkerPoolSize       = 4
+)
+
+// needsChunking decides whether a PDF should be split before extraction:
+// over the size threshold, or over chunkPages pages.
+func needsChunking(pdf []byte, chunkPages int) bool {
+	if len(pdf) > defaultChunkSizeThreshold {
+		return true
+	}
+	pageCount, err := api.PageCount(bytes.NewReader(pdf), nil)
+	if err != nil {
+		return false
+	}
+	return pageCount > chunkPages
+}
+
+// splitPDF breaks pdf into chunkPages-page pieces using pdfcpu.
+func splitPDF(pdf []byte, chunkPages int) ([][]byte, error) {
+	readers, err := api.SplitRaw(bytes.NewReader(pdf), chunkPages, nil)
+	if err != nil {
+		return nil, fmt.Errorf("splitting pdf: %w", err)
+	}
+
+	chunks := make([][]byte, 0, len(readers))
+	for _, rs := range readers {
+		buf := new(bytes.Buffer)
+		if _, err := io.Copy(buf, rs.Reader); err != nil {
+			return nil, fmt.Errorf("reading pdf chunk: %w", err)
+		}
+		chunks = append(chunks, buf.Bytes())
+	}
+	return chunks, nil
+}
+
+type chunkResult struct {
+	index int
+	data  json.RawMessage
+	err   error
+}
+
+// extractChunked runs each PDF chunk through extractor concurrently,
+// bounded by chunkWorkerPoolSize, then merges the per-chunk JSON objects
+// per mergeStrategy. Per-chunk failures don't fail the whole request; they
+// surface in a "_warnings" array on the merged result.
+func extractChunked(ctx context.Context, extractor Extractor, prompt string, chunks [][]byte, schema *openAPISchema, mergeStrategy string) (json.RawMessage, error) {
+	results := make([]chunkResult, len(chunks))
+	sem := make(chan struct{}, chunkWorkerPoolSize)
+	var wg sync.WaitGroup
+
+	for i, chunk := range chunks {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, chunk []byte) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			data, err := extractWithRetry(ctx, extractor, prompt, chunk, schema)
+			results[i] = chunkResult{index: i, data: data, err: err}
+		}(i, chunk)
+	}
+	wg.Wait()
+
+	var warnings []string
+	objects := make([]map[string]any, 0, len(results))
+	for _, res := range results {
+		if res.err != nil {
+			warnings = append(warnings, fmt.Sprintf("chunk %d: %v", res.index, res.err))
+			continue
+		}
+		var obj map[string]any
+		if err := json.Unmarshal(res.data, &obj); err != nil {
+			warnings = append(warnings, fmt.Sprintf("chunk %d: invalid json: %v", res.index, err))
+			continue
+		}
+		objects = append(objects, obj)
+	}
+
+	merged := mergeJSONObjects(objects, mergeStrategy)
+	if len(warnings) > 0 {
+		merged["_warnings"] = warnings
+	}
+
+	return json.Marshal(merged)
+}
+
+// mergeJSONObjects merges per-chunk JSON objects key by key: nested objects
+// recurse, arrays concatenate and dedupe, and scalars are tie-broken by
+// mergeStrategy ("first", "last", "concat" (falls back to first for
+// scalars), "vote").
+func mergeJSONObjects(objs []map[string]any, mergeStrategy string) map[string]any {
+	merged := map[string]any{}
+
+	keys := map[string]bool{}
+	for _, obj := range objs {
+		for k := range obj {
+			keys[k] = true
+		}
+	}
+	ordered := make([]string, 0, len(keys))
+	for k := range keys {
+		ordered = append(ordered, k)
+	}
+	sort.Strings(ordered)
+
+	for _, key := range ordered {
+		values := make([]any, 0, len(objs))
+		for _, obj := range objs {
+			if v, ok := obj[key]; ok {
+				values = append(values, v)
+			}
+		}
+		merged[key] = mergeValues(values, mergeStrategy)
+	}
+	return merged
+}
+
+func mergeValues(values []any, mergeStrategy string) any {
+	if len(values) == 0 {
+		return nil
+	}
+	// Chunks that didn't find a field report null; skip those so they don't
+	// stop the other chunks' objects from recursing or arrays from merging.
+	nonNull := make([]any, 0, len(values))
+	for _, v := range values {
+		if v != nil {
+			nonNull = append(nonNull, v)
+		}
+	}
+	if len(nonNull) > 0 && allObjects(nonNull) {
+		objs := make([]map[string]any, len(nonNull))
+		for i, v := range nonNull {
+			objs[i] = v.(map[string]any)
+		}
+		return mergeJSONObjects(objs, mergeStrategy)
+	}
+	if len(nonNull) > 0 && allArrays(nonNull) {
+		return mergeArrays(nonNull)
+	}
+
+	switch mergeStrategy {
+	case "last":
+		for i := len(values) - 1; i >= 0; i-- {
+			if values[i] != nil {
+				return values[i]
+			}
+		}
+	case "vote":
+		return majorityValue(values)
+	default: // "first", "concat"
+		for _, v := range values {
+			if v != nil {
+				return v
+			}
+		}
+	}
+	return nil
+}
+
+func allObjects(values []any) bool {
+	for _, v := range values {
+		if _, ok := v.(map[string]any); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func allArrays(values []any) bool {
+	for _, v := range values {
+		if _, ok := v.([]any); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func mergeArrays(values []any) []any {
+	seen := map[string]bool{}
+	merged := []any{}
+	for _, v := range values {
+		for _, item := range v.([]any) {
+			b, err := json.Marshal(item)
+			if err != nil {
+				continue
+			}
+			if seen[string(b)] {
+				continue
+			}
+			seen[string(b)] = true
+			merged = append(merged, item)
+		}
+	}
+	return merged
+}
+
+func majorityValue(values []any) any {
+	counts := map[string]int{}
+	reps := map[string]any{}
+	for _, v := range values {
+		b, err := json.Marshal(v)
+		if err != nil {
+			continue
+		}
+		counts[string(b)]++
+		reps[string(b)] = v
+	}
+	best, bestCount := "", -1
+	for k, c := range counts {
+		if c > bestCount {
+			best, bestCount = k, c
+		}
+	}
+	return reps[best]
+}
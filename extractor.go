@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Extractor is implemented by each LLM backend capable of turning a PDF
+// (and an optional response schema) into structured JSON. Prompt building
+// and schema normalization happen once in parseExtractionRequest; each
+// Extractor only has to translate that into its own request shape and
+// decode the reply.
+type Extractor interface {
+	Extract(ctx context.Context, prompt string, pdf []byte, schema *openAPISchema) (json.RawMessage, error)
+	// Model identifies the backend model in use, so callers (e.g. the
+	// response cache) can key on it without knowing the concrete type.
+	Model() string
+}
+
+// selectExtractor picks a backend by name, falling back to LLM_PROVIDER and
+// then "gemini" so existing callers keep working unchanged.
+func selectExtractor(provider string) (Extractor, error) {
+	if provider == "" {
+		provider = os.Getenv("LLM_PROVIDER")
+	}
+	if provider == "" {
+		provider = "gemini"
+	}
+
+	switch provider {
+	case "gemini":
+		apiKey := os.Getenv("GEMINI_API_KEY")
+		if apiKey == "" {
+			return nil, fmt.Errorf("missing GEMINI_API_KEY")
+		}
+		return &geminiExtractor{apiKey: apiKey, model: envOrDefault("GEMINI_MODEL", "gemini-2.0-flash")}, nil
+	case "openai":
+		apiKey := os.Getenv("OPENAI_API_KEY")
+		if apiKey == "" {
+			return nil, fmt.Errorf("missing OPENAI_API_KEY")
+		}
+		return &openaiExtractor{apiKey: apiKey, model: envOrDefault("OPENAI_MODEL", "gpt-4o-mini")}, nil
+	case "anthropic":
+		apiKey := os.Getenv("ANTHROPIC_API_KEY")
+		if apiKey == "" {
+			return nil, fmt.Errorf("missing ANTHROPIC_API_KEY")
+		}
+		return &anthropicExtractor{apiKey: apiKey, model: envOrDefault("ANTHROPIC_MODEL", "claude-3-5-sonnet-latest")}, nil
+	case "ollama":
+		return &ollamaExtractor{host: envOrDefault("OLLAMA_HOST", "http://localhost:11434"), model: envOrDefault("OLLAMA_MODEL", "llama3.1")}, nil
+	default:
+		return nil, fmt.Errorf("unknown provider %q", provider)
+	}
+}
+
+func envOrDefault(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// decodeExtractorResponse trims surrounding whitespace from a backend's raw
+// text reply, defaulting to "{}" when the backend returned nothing. Nothing
+// else is stripped: a reply wrapped in markdown code fences is returned as is.
+func decodeExtractorResponse(text string) json.RawMessage {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		text = "{}"
+	}
+	return json.RawMessage(text)
+}
+
+// extractWithRetry calls the extractor once, and if the reply fails schema
+// validation, retries a single time with the validation error appended to
+// the prompt.
+func extractWithRetry(ctx context.Context, extractor Extractor, prompt string, pdf []byte, schema *openAPISchema) (json.RawMessage, error) {
+	content, err := extractor.Extract(ctx, prompt, pdf, schema)
+	if err != nil {
+		return nil, err
+	}
+
+	if schema == nil {
+		return content, nil
+	}
+
+	if verr := validateAgainstSchema(content, schema); verr != nil {
+		retryPrompt := prompt + fmt.Sprintf("\nThe previous response failed schema validation: %v\nReturn corrected JSON that matches the schema.\n", verr)
+		content, err = extractor.Extract(ctx, retryPrompt, pdf, schema)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return content, nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Gemini request/response types, also used by the streaming endpoint.
+type geminiPart struct {
+	Text       string      `json:"text,omitempty"`
+	InlineData *inlineData `json:"inline_data,omitempty"`
+}
+
+type inlineData struct {
+	MimeType string `json:"mime_type"`
+	Data     string `json:"data"`
+}
+
+type geminiContent struct {
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiRequest struct {
+	Contents         []geminiContent `json:"contents"`
+	GenerationConfig map[string]any  `json:"generationConfig,omitempty"`
+}
+
+type geminiResponse struct {
+	Candidates []struct {
+		Content geminiContent `json:"content"`
+	} `json:"candidates"`
+}
+
+// geminiExtractor calls Gemini's generateContent with structured-output
+// mode: response_mime_type is always JSON, and response_schema is set
+// whenever the caller supplied one.
+type geminiExtractor struct {
+	apiKey string
+	model  string
+}
+
+func (e *geminiExtractor) Extract(ctx context.Context, prompt string, pdf []byte, schema *openAPISchema) (json.RawMessage, error) {
+	parts := []geminiPart{{Text: prompt}}
+	if len(pdf) > 0 {
+		parts = append(parts, geminiPart{InlineData: &inlineData{
+			MimeType: "application/pdf",
+			Data:     base64.StdEncoding.EncodeToString(pdf),
+		}})
+	}
+
+	generationConfig := map[string]any{
+		"temperature":        0.1, // Low temperature for more consistent output
+		"response_mime_type": "application/json",
+	}
+	if schema != nil {
+		generationConfig["response_schema"] = schema
+	}
+
+	req := geminiRequest{
+		Contents:         []geminiContent{{Parts: parts}},
+		GenerationConfig: generationConfig,
+	}
+
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling request: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:generateContent", e.model)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-goog-api-key", e.apiKey)
+
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("gemini request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("gemini error %d: %s", resp.StatusCode, string(body))
+	}
+
+	var gr geminiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&gr); err != nil {
+		return nil, fmt.Errorf("decoding gemini response: %w", err)
+	}
+
+	content := ""
+	if len(gr.Candidates) > 0 && len(gr.Candidates[0].Content.Parts) > 0 {
+		content = gr.Candidates[0].Content.Parts[0].Text
+	}
+	return decodeExtractorResponse(content), nil
+}
+
+func (e *geminiExtractor) Model() string {
+	return e.model
+}
@@ -1,21 +1,16 @@
 package main
 
 import (
-	"bytes"
 	"context"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"os"
+	"strconv"
 	"strings"
 	"time"
 
-	"net"
-	"sync"
-
 	"github.com/joho/godotenv"
 )
 
@@ -33,141 +28,37 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 	io.WriteString(w, `{"ok":true}`)
 }
 
-// Simple in-memory rate limiter per client key
-type rateLimiter struct {
-    mu     sync.Mutex
-    hits   map[string][]time.Time
-    limit  int
-    window time.Duration
-}
-
-func newRateLimiter(limit int, window time.Duration) *rateLimiter {
-    return &rateLimiter{hits: make(map[string][]time.Time), limit: limit, window: window}
+// extractionRequest holds everything every handler needs to run an
+// extraction: the optional PDF, the optional response schema, and the
+// prompt built from them. Each Extractor turns this into its own
+// provider-specific request shape.
+type extractionRequest struct {
+	fileBytes      []byte
+	hasFile        bool
+	responseSchema *openAPISchema
+	prompt         string
 }
 
-func (rl *rateLimiter) allow(key string) (bool, time.Duration) {
-    now := time.Now()
-    cutoff := now.Add(-rl.window)
-    rl.mu.Lock()
-    defer rl.mu.Unlock()
-
-    q := rl.hits[key]
-    // drop old entries
-    i := 0
-    for i < len(q) && q[i].Before(cutoff) {
-        i++
-    }
-    if i > 0 {
-        q = q[i:]
-    }
-    if len(q) >= rl.limit {
-        // time until oldest entry exits window
-        retry := rl.window - now.Sub(q[0])
-        rl.hits[key] = q
-        return false, retry
-    }
-    q = append(q, now)
-    rl.hits[key] = q
-    return true, 0
-}
-
-func clientKey(r *http.Request) string {
-    if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-        parts := strings.Split(xff, ",")
-        return strings.TrimSpace(parts[0])
-    }
-    host, _, err := net.SplitHostPort(r.RemoteAddr)
-    if err == nil {
-        return host
-    }
-    return r.RemoteAddr
-}
-
-func (rl *rateLimiter) Middleware(next http.Handler) http.Handler {
-    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-        if r.Method == http.MethodOptions {
-            allowCORS(w)
-            w.WriteHeader(http.StatusNoContent)
-            return
-        }
-        key := clientKey(r)
-        ok, retry := rl.allow(key)
-        if !ok {
-            allowCORS(w)
-            w.Header().Set("Retry-After", fmt.Sprintf("%d", int(retry.Seconds())))
-            w.Header().Set("Content-Type", "application/json")
-            w.WriteHeader(http.StatusTooManyRequests)
-            io.WriteString(w, fmt.Sprintf(`{"error":"rate limit exceeded","limit":%d,"window_seconds":%d}`, rl.limit, int(rl.window.Seconds())))
-            return
-        }
-        next.ServeHTTP(w, r)
-    })
-}
-
-// Gemini request/response types
-type geminiPart struct {
-	Text       string      `json:"text,omitempty"`
-	InlineData *inlineData `json:"inline_data,omitempty"`
-}
-
-type inlineData struct {
-	MimeType string `json:"mime_type"`
-	Data     string `json:"data"`
-}
-
-type geminiContent struct {
-	Parts []geminiPart `json:"parts"`
-}
-
-type geminiRequest struct {
-	Contents         []geminiContent `json:"contents"`
-	GenerationConfig map[string]any  `json:"generationConfig,omitempty"`
-}
-
-type geminiResponse struct {
-	Candidates []struct {
-		Content geminiContent `json:"content"`
-	} `json:"candidates"`
-}
-
-
-
-func parseHandler(w http.ResponseWriter, r *http.Request) {
-	allowCORS(w)
-	if r.Method == http.MethodOptions {
-		w.WriteHeader(http.StatusNoContent)
-		return
-	}
-	if r.Method != http.MethodPost {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	apiKey := os.Getenv("GEMINI_API_KEY")
-	if apiKey == "" {
-		http.Error(w, "missing GEMINI_API_KEY", http.StatusInternalServerError)
-		return
-	}
-
+// parseExtractionRequest reads the multipart form shared by /api/parse and
+// /api/parse/stream: an optional "file" and an optional "schema", either a
+// JSON Schema object or a bare comma-separated field list.
+func parseExtractionRequest(r *http.Request) (*extractionRequest, error) {
 	if err := r.ParseMultipartForm(25 << 20); err != nil { // 25MB
-		http.Error(w, "invalid form", http.StatusBadRequest)
-		return
+		return nil, fmt.Errorf("invalid form")
 	}
 
 	var fileBytes []byte
 	var hasFile bool
-	
+
 	f, _, err := r.FormFile("file")
 	if err == nil {
 		defer f.Close()
 		hasFile = true
-		
+
 		const max = 6 << 20 // 6MB cap
 		b, readErr := io.ReadAll(io.LimitReader(f, max))
 		if readErr != nil {
-			log.Printf("Error reading file: %v", readErr)
-			http.Error(w, "error reading file", http.StatusBadRequest)
-			return
+			return nil, fmt.Errorf("error reading file: %w", readErr)
 		}
 		fileBytes = b
 	}
@@ -176,139 +67,136 @@ func parseHandler(w http.ResponseWriter, r *http.Request) {
 	hasSchema := schema != ""
 
 	if !hasFile && !hasSchema {
-		http.Error(w, "either file or schema must be provided", http.StatusBadRequest)
-		return
+		return nil, fmt.Errorf("either file or schema must be provided")
 	}
 
-	var promptBuilder strings.Builder
-	
+	var responseSchema *openAPISchema
 	if hasSchema {
-		promptBuilder.WriteString("IMPORTANT: You must return ONLY a simple JSON object with the requested data fields.\n")
-		promptBuilder.WriteString("DO NOT return any structure with 'file', 'pages', 'tables', or 'text' keys.\n")
-		promptBuilder.WriteString("DO NOT return arrays of text chunks or metadata.\n")
-		promptBuilder.WriteString("Extract the actual data values and return them directly.\n\n")
-		
-		promptBuilder.WriteString("Example of what NOT to return:\n")
-		promptBuilder.WriteString(`{"file": null, "pages": 1, "tables": [], "text": [...]}` + "\n\n")
-		
-		promptBuilder.WriteString("Example of correct format:\n")
-		promptBuilder.WriteString(`{"name": "John Doe", "contact": "1234567890", "application_no": "ABC123"}` + "\n\n")
-		
-		if strings.HasPrefix(strings.TrimSpace(schema), "{") {
-			promptBuilder.WriteString("Required JSON structure:\n")
-			promptBuilder.WriteString(schema)
-			promptBuilder.WriteString("\n\n")
-		} else {
-			promptBuilder.WriteString("Required fields to extract: ")
-			promptBuilder.WriteString(schema)
-			promptBuilder.WriteString("\n\n")
-		}
-		
-		if hasFile {
-			promptBuilder.WriteString("Read the PDF content and extract only the requested field values. Return the simple JSON object with extracted values only.\n")
+		if strings.HasPrefix(schema, "{") {
+			responseSchema, err = translateJSONSchema([]byte(schema))
+			if err != nil {
+				return nil, fmt.Errorf("invalid schema: %w", err)
+			}
 		} else {
-			promptBuilder.WriteString("Create a JSON object with the specified keys, using null for unavailable data.\n")
+			responseSchema = fieldListSchema(schema)
 		}
-	} else {
-		promptBuilder.WriteString("IMPORTANT: Extract meaningful data from the PDF as a simple JSON object.\n")
-		promptBuilder.WriteString("DO NOT return metadata like 'file', 'pages', 'tables', or 'text' arrays.\n")
-		promptBuilder.WriteString("DO NOT return document structure information.\n")
-		promptBuilder.WriteString("Extract actual content values like names, numbers, addresses, etc.\n\n")
-		
-		promptBuilder.WriteString("Example of what NOT to return:\n")
-		promptBuilder.WriteString(`{"file": null, "pages": 1, "tables": [], "text": [...]}` + "\n\n")
-		
-		promptBuilder.WriteString("Example of correct format:\n")
-		promptBuilder.WriteString(`{"document_type": "Application", "name": "John Doe", "id": "123456"}` + "\n\n")
-		
-		promptBuilder.WriteString("Analyze the PDF and return only the extracted content values.\n")
 	}
 
-	parts := []geminiPart{{Text: promptBuilder.String()}}
-	
-	if hasFile {
-		parts = append(parts, geminiPart{InlineData: &inlineData{
-			MimeType: "application/pdf",
-			Data:     base64.StdEncoding.EncodeToString(fileBytes),
-		}})
+	var promptBuilder strings.Builder
+	if hasSchema {
+		promptBuilder.WriteString("Extract the requested data fields from the document into JSON matching the response schema.\n")
+		promptBuilder.WriteString("Use null for any field that cannot be found.\n")
+	} else {
+		promptBuilder.WriteString("Extract the meaningful data from the document as a JSON object: names, numbers, dates, addresses and similar content values.\n")
 	}
-
-	generationConfig := map[string]any{
-		"temperature": 0.1, // Low temperature for more consistent output
+	if !hasFile {
+		promptBuilder.WriteString("No file was provided; create the JSON object from the schema alone, using null for every field.\n")
 	}
-	
 
+	return &extractionRequest{
+		fileBytes:      fileBytes,
+		hasFile:        hasFile,
+		responseSchema: responseSchema,
+		prompt:         promptBuilder.String(),
+	}, nil
+}
 
-	req := geminiRequest{
-		Contents:         []geminiContent{{Parts: parts}},
-		GenerationConfig: generationConfig,
-	}
+// newParseHandler builds the /api/parse handler, closing over the response
+// cache so a repeat upload of the same file+schema+model, with the same
+// chunk_pages and merge_strategy, skips the LLM call entirely.
+func newParseHandler(cache Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		allowCORS(w)
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
 
-	payload, err := json.Marshal(req)
-	if err != nil {
-		log.Printf("Error marshaling request: %v", err)
-		http.Error(w, "internal error", http.StatusInternalServerError)
-		return
-	}
+		er, err := parseExtractionRequest(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
-	defer cancel()
-	
-	endpoint := "https://generativelanguage.googleapis.com/v1beta/models/gemini-2.0-flash:generateContent"
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
-	if err != nil {
-		log.Printf("Error creating request: %v", err)
-		http.Error(w, "internal error", http.StatusInternalServerError)
-		return
-	}
-	
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("X-goog-api-key", apiKey)
+		extractor, err := selectExtractor(strings.TrimSpace(r.FormValue("provider")))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 
-	resp, err := http.DefaultClient.Do(httpReq)
-	if err != nil {
-		log.Println("gemini request error:", err)
-		http.Error(w, "upstream error", http.StatusBadGateway)
-		return
-	}
-	defer resp.Body.Close()
+		chunkPages := defaultChunkMaxPages
+		if v := strings.TrimSpace(r.FormValue("chunk_pages")); v != "" {
+			if n, convErr := strconv.Atoi(v); convErr == nil && n > 0 {
+				chunkPages = n
+			}
+		}
+		mergeStrategy := strings.TrimSpace(r.FormValue("merge_strategy"))
+		if mergeStrategy == "" {
+			mergeStrategy = "first"
+		}
 
-	if resp.StatusCode >= 400 {
-		body, _ := io.ReadAll(resp.Body)
-		log.Printf("gemini error %d: %s\n", resp.StatusCode, string(body))
-		http.Error(w, "gemini error", http.StatusBadGateway)
-		return
-	}
+		noCache := r.URL.Query().Get("no_cache") == "1"
+		key := cacheKey(er.fileBytes, er.responseSchema, extractor.Model(), chunkPages, mergeStrategy)
+
+		if !noCache {
+			if cached, hit := cache.Get(r.Context(), key); hit {
+				w.Header().Set("X-Cache", "HIT")
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusOK)
+				w.Write(cached)
+				return
+			}
+		}
 
-	var gr geminiResponse
-	if err := json.NewDecoder(resp.Body).Decode(&gr); err != nil {
-		log.Println("gemini decode error:", err)
-		http.Error(w, "decode error", http.StatusBadGateway)
-		return
-	}
+		var content json.RawMessage
+		if er.hasFile && needsChunking(er.fileBytes, chunkPages) {
+			ctx, cancel := context.WithTimeout(r.Context(), 5*time.Minute)
+			defer cancel()
+
+			chunks, splitErr := splitPDF(er.fileBytes, chunkPages)
+			if splitErr != nil {
+				log.Println("pdf split error:", splitErr)
+				http.Error(w, "failed to split pdf", http.StatusBadGateway)
+				return
+			}
+			content, err = extractChunked(ctx, extractor, er.prompt, chunks, er.responseSchema, mergeStrategy)
+		} else {
+			ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
+			defer cancel()
 
-	content := ""
-	if len(gr.Candidates) > 0 && len(gr.Candidates[0].Content.Parts) > 0 {
-		content = gr.Candidates[0].Content.Parts[0].Text
-	}
-	
-	if strings.TrimSpace(content) == "" {
-		content = "{}"
-	}
+			content, err = extractWithRetry(ctx, extractor, er.prompt, er.fileBytes, er.responseSchema)
+		}
+		if err != nil {
+			log.Println("extraction error:", err)
+			http.Error(w, "upstream error", http.StatusBadGateway)
+			return
+		}
 
-  fmt.Println(content)
+		if !noCache {
+			cache.Set(r.Context(), key, content, cacheTTL())
+		}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(content))
+		w.Header().Set("X-Cache", "MISS")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(content)
+	}
 }
 
 func main() {
 	_ = godotenv.Load(".env")
 
     mux := http.NewServeMux()
-    rl := newRateLimiter(50, 3*time.Hour)
-    mux.Handle("/api/parse", rl.Middleware(http.HandlerFunc(parseHandler)))
+    rl := newRateLimiter(50, 10, 10000) // 50 req/min, burst 10, cap 10k tracked keys
+    cache := selectCacheBackend()
+    mux.Handle("/api/parse", rl.Middleware(newParseHandler(cache)))
+    mux.Handle("/api/parse/stream", rl.Middleware(http.HandlerFunc(parseStreamHandler)))
+    mux.HandleFunc("/api/quota", rl.quotaHandler)
+    mux.HandleFunc("/api/cache/", newCacheDeleteHandler(cache))
     mux.HandleFunc("/healthz", healthHandler)
 
 	addr := ":8080"
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ollamaExtractor calls a local Ollama /api/generate endpoint with
+// format: "json". Ollama's generate endpoint has no document input, so
+// when a schema is supplied it's appended to the prompt as a contract,
+// matching anthropicExtractor's fallback.
+type ollamaExtractor struct {
+	host  string
+	model string
+}
+
+type ollamaRequest struct {
+	Model  string `json:"model"`
+	Prompt string `json:"prompt"`
+	Format string `json:"format"`
+	Stream bool   `json:"stream"`
+}
+
+type ollamaResponse struct {
+	Response string `json:"response"`
+	Done     bool   `json:"done"`
+}
+
+func (e *ollamaExtractor) Extract(ctx context.Context, prompt string, pdf []byte, schema *openAPISchema) (json.RawMessage, error) {
+	if schema != nil {
+		schemaJSON, err := json.Marshal(schema)
+		if err != nil {
+			return nil, fmt.Errorf("marshaling schema: %w", err)
+		}
+		prompt += "\n\nRespond with JSON matching this schema exactly:\n" + string(schemaJSON)
+	}
+	if len(pdf) > 0 {
+		prompt += "\n\nNote: this backend does not accept file attachments; no PDF content was sent."
+	}
+
+	req := ollamaRequest{Model: e.model, Prompt: prompt, Format: "json", Stream: false}
+
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, e.host+"/api/generate", bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("ollama request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("ollama error %d: %s", resp.StatusCode, string(body))
+	}
+
+	var or ollamaResponse
+	if err := json.NewDecoder(resp.Body).Decode(&or); err != nil {
+		return nil, fmt.Errorf("decoding ollama response: %w", err)
+	}
+
+	return decodeExtractorResponse(or.Response), nil
+}
+
+func (e *ollamaExtractor) Model() string {
+	return e.model
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// openaiExtractor calls OpenAI's Responses API, attaching the PDF as an
+// input_file and asking for a json_schema-formatted reply when the caller
+// supplied a schema.
+type openaiExtractor struct {
+	apiKey string
+	model  string
+}
+
+type openaiContentPart struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	Filename string `json:"filename,omitempty"`
+	FileData string `json:"file_data,omitempty"`
+}
+
+type openaiInputMessage struct {
+	Role    string              `json:"role"`
+	Content []openaiContentPart `json:"content"`
+}
+
+type openaiTextFormat struct {
+	Type   string         `json:"type"`
+	Name   string         `json:"name,omitempty"`
+	Schema *openAPISchema `json:"schema,omitempty"`
+	Strict bool           `json:"strict,omitempty"`
+}
+
+type openaiRequest struct {
+	Model string               `json:"model"`
+	Input []openaiInputMessage `json:"input"`
+	Text  *struct {
+		Format openaiTextFormat `json:"format"`
+	} `json:"text,omitempty"`
+}
+
+type openaiResponse struct {
+	Output []struct {
+		Type    string `json:"type"`
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+	} `json:"output"`
+}
+
+func (e *openaiExtractor) Extract(ctx context.Context, prompt string, pdf []byte, schema *openAPISchema) (json.RawMessage, error) {
+	content := []openaiContentPart{{Type: "input_text", Text: prompt}}
+	if len(pdf) > 0 {
+		content = append(content, openaiContentPart{
+			Type:     "input_file",
+			Filename: "document.pdf",
+			FileData: "data:application/pdf;base64," + base64.StdEncoding.EncodeToString(pdf),
+		})
+	}
+
+	req := openaiRequest{
+		Model: e.model,
+		Input: []openaiInputMessage{{Role: "user", Content: content}},
+	}
+	if schema != nil {
+		// Not Strict: openAPISchema is translated for Gemini's OpenAPI subset
+		// (bare "nullable", no "additionalProperties", "required" listing only
+		// the fields that must be present). OpenAI's strict mode enforces a
+		// narrower, stricter shape and would reject it with a 400.
+		req.Text = &struct {
+			Format openaiTextFormat `json:"format"`
+		}{Format: openaiTextFormat{Type: "json_schema", Name: "extraction", Schema: schema}}
+	}
+
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://api.openai.com/v1/responses", bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+e.apiKey)
+
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("openai request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("openai error %d: %s", resp.StatusCode, string(body))
+	}
+
+	var or openaiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&or); err != nil {
+		return nil, fmt.Errorf("decoding openai response: %w", err)
+	}
+
+	text := ""
+	for _, out := range or.Output {
+		for _, part := range out.Content {
+			text += part.Text
+		}
+	}
+	return decodeExtractorResponse(text), nil
+}
+
+func (e *openaiExtractor) Model() string {
+	return e.model
+}
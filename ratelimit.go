@@ -0,0 +1,273 @@
+package main
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateLimitConfig is a per-key override: requests per minute and burst
+// capacity. Loaded from RATE_LIMITS_JSON, keyed by the same string
+// clientKey returns (the bearer token or client IP).
+type rateLimitConfig struct {
+	RPM   int `json:"rpm"`
+	Burst int `json:"burst"`
+}
+
+// tokenBucket refills continuously at `rate` tokens/sec up to `burst`, and
+// each request consumes one token.
+type tokenBucket struct {
+	mu         sync.Mutex
+	tokens     float64
+	rate       float64
+	burst      int
+	lastRefill time.Time
+	lastSeen   time.Time
+}
+
+func newTokenBucket(rpm, burst int) *tokenBucket {
+	now := time.Now()
+	return &tokenBucket{
+		tokens:     float64(burst),
+		rate:       float64(rpm) / 60,
+		burst:      burst,
+		lastRefill: now,
+		lastSeen:   now,
+	}
+}
+
+// allow consumes a token if one is available. It returns whether the
+// request is allowed, how long to wait before retrying otherwise, and the
+// remaining token count for X-RateLimit-Remaining.
+func (b *tokenBucket) allow() (ok bool, retryAfter time.Duration, remaining int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	elapsed := now.Sub(b.lastRefill).Seconds()
+	b.tokens += elapsed * b.rate
+	if b.tokens > float64(b.burst) {
+		b.tokens = float64(b.burst)
+	}
+	b.lastRefill = now
+	b.lastSeen = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0, int(b.tokens)
+	}
+
+	deficit := 1 - b.tokens
+	retry := time.Duration(deficit / b.rate * float64(time.Second))
+	return false, retry, 0
+}
+
+func (b *tokenBucket) idleSince(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return now.Sub(b.lastSeen)
+}
+
+// rateLimiter is a token-bucket limiter keyed by Authorization bearer token
+// or client IP, backed by an LRU with a max-entry cap so an unbounded
+// stream of distinct keys can't grow memory forever, plus a background
+// sweep that evicts buckets idle longer than 2*window.
+type rateLimiter struct {
+	mu         sync.Mutex
+	buckets    map[string]*list.Element // key -> element in lru, Value is *bucketEntry
+	lru        *list.List
+	maxEntries int
+
+	defaultRPM   int
+	defaultBurst int
+	window       time.Duration
+	overrides    map[string]rateLimitConfig
+}
+
+type bucketEntry struct {
+	key    string
+	bucket *tokenBucket
+}
+
+func newRateLimiter(defaultRPM, defaultBurst, maxEntries int) *rateLimiter {
+	rl := &rateLimiter{
+		buckets:      make(map[string]*list.Element),
+		lru:          list.New(),
+		maxEntries:   maxEntries,
+		defaultRPM:   defaultRPM,
+		defaultBurst: defaultBurst,
+		window:       time.Minute,
+		overrides:    loadRateLimitOverrides(),
+	}
+	go rl.cleanupLoop()
+	return rl
+}
+
+// loadRateLimitOverrides reads per-key rpm/burst overrides, e.g.
+// {"key_abc":{"rpm":120,"burst":20}}. RATE_LIMITS_JSON takes precedence when
+// set; otherwise RATE_LIMITS_FILE is read from disk, for deployments that
+// would rather not cram the config into an env var.
+func loadRateLimitOverrides() map[string]rateLimitConfig {
+	raw := os.Getenv("RATE_LIMITS_JSON")
+	if raw == "" {
+		path := os.Getenv("RATE_LIMITS_FILE")
+		if path == "" {
+			return nil
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("reading RATE_LIMITS_FILE: %v", err)
+			return nil
+		}
+		raw = string(b)
+	}
+	var overrides map[string]rateLimitConfig
+	if err := json.Unmarshal([]byte(raw), &overrides); err != nil {
+		log.Printf("parsing rate limit overrides: %v", err)
+		return nil
+	}
+	return overrides
+}
+
+func (rl *rateLimiter) limitsFor(key string) (rpm, burst int) {
+	if cfg, ok := rl.overrides[key]; ok {
+		return cfg.RPM, cfg.Burst
+	}
+	return rl.defaultRPM, rl.defaultBurst
+}
+
+// bucketFor returns the bucket for key, creating it (and evicting the
+// least-recently-used bucket if over capacity) on first use.
+func (rl *rateLimiter) bucketFor(key string) *tokenBucket {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if el, ok := rl.buckets[key]; ok {
+		rl.lru.MoveToFront(el)
+		return el.Value.(*bucketEntry).bucket
+	}
+
+	rpm, burst := rl.limitsFor(key)
+	entry := &bucketEntry{key: key, bucket: newTokenBucket(rpm, burst)}
+	el := rl.lru.PushFront(entry)
+	rl.buckets[key] = el
+
+	if rl.maxEntries > 0 && rl.lru.Len() > rl.maxEntries {
+		oldest := rl.lru.Back()
+		if oldest != nil {
+			rl.lru.Remove(oldest)
+			delete(rl.buckets, oldest.Value.(*bucketEntry).key)
+		}
+	}
+
+	return entry.bucket
+}
+
+// cleanupLoop evicts buckets idle longer than 2*window so keys that stop
+// sending requests don't linger in memory forever.
+func (rl *rateLimiter) cleanupLoop() {
+	ticker := time.NewTicker(rl.window)
+	defer ticker.Stop()
+	for range ticker.C {
+		now := time.Now()
+		maxIdle := 2 * rl.window
+
+		rl.mu.Lock()
+		for key, el := range rl.buckets {
+			entry := el.Value.(*bucketEntry)
+			if entry.bucket.idleSince(now) > maxIdle {
+				rl.lru.Remove(el)
+				delete(rl.buckets, key)
+			}
+		}
+		rl.mu.Unlock()
+	}
+}
+
+// clientKey identifies the caller by API key when present, else client IP.
+func clientKey(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		if key, ok := strings.CutPrefix(auth, "Bearer "); ok {
+			return strings.TrimSpace(key)
+		}
+	}
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		parts := strings.Split(xff, ",")
+		return strings.TrimSpace(parts[0])
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+func (rl *rateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			allowCORS(w)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		key := clientKey(r)
+		rpm, burst := rl.limitsFor(key)
+		bucket := rl.bucketFor(key)
+		ok, retry, remaining := bucket.allow()
+
+		w.Header().Set("X-RateLimit-Limit", fmt.Sprintf("%d", rpm))
+		w.Header().Set("X-RateLimit-Remaining", fmt.Sprintf("%d", remaining))
+		w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", int(retry.Seconds())))
+
+		if !ok {
+			allowCORS(w)
+			w.Header().Set("Retry-After", fmt.Sprintf("%d", int(retry.Seconds())))
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprintf(w, `{"error":"rate limit exceeded","limit":%d,"burst":%d}`, rpm, burst)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// quotaHandler reports the remaining tokens and reset time for the caller's
+// key without consuming a token itself.
+func (rl *rateLimiter) quotaHandler(w http.ResponseWriter, r *http.Request) {
+	allowCORS(w)
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	key := clientKey(r)
+	rpm, burst := rl.limitsFor(key)
+	bucket := rl.bucketFor(key)
+
+	bucket.mu.Lock()
+	now := time.Now()
+	elapsed := now.Sub(bucket.lastRefill).Seconds()
+	tokens := bucket.tokens + elapsed*bucket.rate
+	if tokens > float64(bucket.burst) {
+		tokens = float64(bucket.burst)
+	}
+	remaining := int(tokens)
+	var resetSeconds int
+	if remaining < burst {
+		resetSeconds = int((1 - (tokens - float64(remaining))) / bucket.rate)
+	}
+	bucket.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-RateLimit-Limit", fmt.Sprintf("%d", rpm))
+	w.Header().Set("X-RateLimit-Remaining", fmt.Sprintf("%d", remaining))
+	w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", resetSeconds))
+	fmt.Fprintf(w, `{"limit":%d,"burst":%d,"remaining":%d,"reset_seconds":%d}`, rpm, burst, remaining, resetSeconds)
+}
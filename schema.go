@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// openAPISchema is the minimal OpenAPI 3.0 schema subset that Gemini's
+// generationConfig.response_schema accepts. It is deliberately smaller than
+// JSON Schema: no $ref, no $schema/$id, no oneOf/patternProperties.
+type openAPISchema struct {
+	Type        string                    `json:"type,omitempty"`
+	Format      string                    `json:"format,omitempty"`
+	Description string                    `json:"description,omitempty"`
+	Nullable    bool                      `json:"nullable,omitempty"`
+	Enum        []string                  `json:"enum,omitempty"`
+	Items       *openAPISchema            `json:"items,omitempty"`
+	Properties  map[string]*openAPISchema `json:"properties,omitempty"`
+	Required    []string                  `json:"required,omitempty"`
+}
+
+// fieldListSchema builds an object schema of nullable strings from a bare,
+// comma-separated field list like "name, contact, application_no".
+func fieldListSchema(fields string) *openAPISchema {
+	props := map[string]*openAPISchema{}
+	for _, f := range strings.Split(fields, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		props[f] = &openAPISchema{Type: "string", Nullable: true}
+	}
+	return &openAPISchema{Type: "object", Properties: props}
+}
+
+// translateJSONSchema converts a JSON Schema draft-07 document into the
+// OpenAPI 3.0 subset Gemini accepts as response_schema: $schema/$id are
+// dropped, local $refs are inlined from "definitions"/"$defs", and
+// unsupported keywords (oneOf, patternProperties) are silently omitted.
+func translateJSONSchema(raw []byte) (*openAPISchema, error) {
+	var doc map[string]any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("invalid json schema: %w", err)
+	}
+	defs := map[string]any{}
+	if d, ok := doc["definitions"].(map[string]any); ok {
+		defs = d
+	}
+	if d, ok := doc["$defs"].(map[string]any); ok {
+		for k, v := range d {
+			defs[k] = v
+		}
+	}
+	return convertSchemaNode(doc, defs, map[string]bool{})
+}
+
+// convertSchemaNode walks node into an openAPISchema, recursively resolving
+// $refs. seen tracks the names of $refs currently being resolved on this
+// recursion path so that circular definitions (directly or mutually
+// recursive) fail with an error instead of recursing until the stack
+// overflows.
+func convertSchemaNode(node map[string]any, defs map[string]any, seen map[string]bool) (*openAPISchema, error) {
+	if ref, ok := node["$ref"].(string); ok {
+		name, resolved, err := resolveRef(ref, defs)
+		if err != nil {
+			return nil, err
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("circular $ref: %s", ref)
+		}
+		seen[name] = true
+		defer delete(seen, name)
+		return convertSchemaNode(resolved, defs, seen)
+	}
+
+	out := &openAPISchema{}
+
+	if t, ok := node["type"].(string); ok {
+		out.Type = mapSchemaType(t)
+	}
+	if d, ok := node["description"].(string); ok {
+		out.Description = d
+	}
+	if f, ok := node["format"].(string); ok {
+		out.Format = f
+	}
+	if nullable, ok := node["nullable"].(bool); ok {
+		out.Nullable = nullable
+	}
+
+	if rawEnum, ok := node["enum"].([]any); ok {
+		for _, v := range rawEnum {
+			if s, ok := v.(string); ok {
+				out.Enum = append(out.Enum, s)
+			}
+		}
+	}
+
+	if items, ok := node["items"].(map[string]any); ok {
+		itemSchema, err := convertSchemaNode(items, defs, seen)
+		if err != nil {
+			return nil, err
+		}
+		out.Items = itemSchema
+	}
+
+	if props, ok := node["properties"].(map[string]any); ok {
+		out.Properties = map[string]*openAPISchema{}
+		for name, v := range props {
+			propNode, ok := v.(map[string]any)
+			if !ok {
+				continue
+			}
+			propSchema, err := convertSchemaNode(propNode, defs, seen)
+			if err != nil {
+				return nil, err
+			}
+			out.Properties[name] = propSchema
+		}
+	}
+
+	if required, ok := node["required"].([]any); ok {
+		for _, v := range required {
+			if s, ok := v.(string); ok {
+				out.Required = append(out.Required, s)
+			}
+		}
+	}
+
+	// oneOf/patternProperties and other unsupported keywords are dropped:
+	// Gemini's response_schema has no equivalent.
+
+	if out.Type == "" && out.Properties != nil {
+		out.Type = "object"
+	}
+
+	return out, nil
+}
+
+// resolveRef resolves ref against defs, returning the bare definition name
+// alongside the resolved node so callers can track it for cycle detection.
+func resolveRef(ref string, defs map[string]any) (string, map[string]any, error) {
+	const defsPrefix = "#/definitions/"
+	const dollarDefsPrefix = "#/$defs/"
+
+	var name string
+	switch {
+	case strings.HasPrefix(ref, defsPrefix):
+		name = strings.TrimPrefix(ref, defsPrefix)
+	case strings.HasPrefix(ref, dollarDefsPrefix):
+		name = strings.TrimPrefix(ref, dollarDefsPrefix)
+	default:
+		return "", nil, fmt.Errorf("unsupported $ref: %s", ref)
+	}
+
+	resolved, ok := defs[name].(map[string]any)
+	if !ok {
+		return "", nil, fmt.Errorf("unresolved $ref: %s", ref)
+	}
+	return name, resolved, nil
+}
+
+func mapSchemaType(t string) string {
+	switch t {
+	case "integer", "number", "boolean", "array", "object", "string":
+		return t
+	default:
+		return "string"
+	}
+}
+
+// validateAgainstSchema performs a structural check of data against schema:
+// object/array/scalar types line up and required properties are present.
+// It is not a full JSON Schema validator, just enough to catch the shapes
+// Gemini tends to drift into (e.g. returning the whole document instead of
+// the requested fields).
+func validateAgainstSchema(data []byte, schema *openAPISchema) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("response is not valid json: %w", err)
+	}
+	return validateValue(v, schema, "$")
+}
+
+func validateValue(v any, schema *openAPISchema, path string) error {
+	if schema == nil {
+		return nil
+	}
+	if v == nil {
+		if schema.Nullable || schema.Type == "" {
+			return nil
+		}
+		return fmt.Errorf("%s: expected %s, got null", path, schema.Type)
+	}
+
+	switch schema.Type {
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: expected object, got %T", path, v)
+		}
+		for _, req := range schema.Required {
+			if _, ok := obj[req]; !ok {
+				return fmt.Errorf("%s: missing required field %q", path, req)
+			}
+		}
+		for name, propSchema := range schema.Properties {
+			propVal, ok := obj[name]
+			if !ok {
+				continue
+			}
+			if err := validateValue(propVal, propSchema, path+"."+name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			return fmt.Errorf("%s: expected array, got %T", path, v)
+		}
+		if schema.Items != nil {
+			for i, item := range arr {
+				if err := validateValue(item, schema.Items, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case "string":
+		if _, ok := v.(string); !ok {
+			return fmt.Errorf("%s: expected string, got %T", path, v)
+		}
+	case "number", "integer":
+		if _, ok := v.(float64); !ok {
+			return fmt.Errorf("%s: expected number, got %T", path, v)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s: expected boolean, got %T", path, v)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sseWriter frames Server-Sent Events onto an http.ResponseWriter, flushing
+// after every event so the client sees it as soon as it's produced. It's
+// intentionally generic so later streaming endpoints can reuse it. Writes
+// are serialized with mu since the keepalive goroutine and the caller's
+// main loop both write to the same ResponseWriter concurrently.
+type sseWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	mu      sync.Mutex
+}
+
+func newSSEWriter(w http.ResponseWriter) (*sseWriter, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, fmt.Errorf("streaming not supported by response writer")
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	return &sseWriter{w: w, flusher: flusher}, nil
+}
+
+// writeEvent writes a named SSE frame, splitting multi-line data across
+// multiple "data:" lines as the spec requires.
+func (s *sseWriter) writeEvent(event, data string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fmt.Fprintf(s.w, "event: %s\n", event)
+	for _, line := range strings.Split(data, "\n") {
+		fmt.Fprintf(s.w, "data: %s\n", line)
+	}
+	fmt.Fprint(s.w, "\n")
+	s.flusher.Flush()
+}
+
+// comment writes an SSE comment line, used here for keepalives.
+func (s *sseWriter) comment(text string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fmt.Fprintf(s.w, ": %s\n\n", text)
+	s.flusher.Flush()
+}
+
+// parseStreamHandler relays incremental Gemini output as SSE so the
+// frontend can render fields as they arrive instead of waiting for the full
+// extraction to finish behind a single long request.
+func parseStreamHandler(w http.ResponseWriter, r *http.Request) {
+	allowCORS(w)
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	apiKey := os.Getenv("GEMINI_API_KEY")
+	if apiKey == "" {
+		http.Error(w, "missing GEMINI_API_KEY", http.StatusInternalServerError)
+		return
+	}
+
+	er, err := parseExtractionRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sse, err := newSSEWriter(w)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+
+	if err := streamFromGemini(r.Context(), apiKey, er.prompt, er.fileBytes, er.hasFile, er.responseSchema, sse); err != nil {
+		log.Println("gemini stream error:", err)
+		sse.writeEvent("error", err.Error())
+	}
+}
+
+// streamFromGemini calls streamGenerateContent and relays each text delta
+// as a "delta" SSE event, finishing with a "done" event carrying the
+// assembled JSON. It honors ctx cancellation so a client disconnect stops
+// the upstream request, and emits a keepalive comment every 15s.
+func streamFromGemini(ctx context.Context, apiKey, prompt string, fileBytes []byte, hasFile bool, schema *openAPISchema, sse *sseWriter) error {
+	parts := []geminiPart{{Text: prompt}}
+	if hasFile {
+		parts = append(parts, geminiPart{InlineData: &inlineData{
+			MimeType: "application/pdf",
+			Data:     base64.StdEncoding.EncodeToString(fileBytes),
+		}})
+	}
+
+	generationConfig := map[string]any{
+		"temperature":        0.1, // Low temperature for more consistent output
+		"response_mime_type": "application/json",
+	}
+	if schema != nil {
+		generationConfig["response_schema"] = schema
+	}
+
+	req := geminiRequest{
+		Contents:         []geminiContent{{Parts: parts}},
+		GenerationConfig: generationConfig,
+	}
+
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("marshaling request: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	model := envOrDefault("GEMINI_MODEL", "gemini-2.0-flash")
+	endpoint := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:streamGenerateContent?alt=sse", model)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-goog-api-key", apiKey)
+
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("gemini request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("gemini error %d: %s", resp.StatusCode, string(body))
+	}
+
+	keepaliveDone := make(chan struct{})
+	defer close(keepaliveDone)
+	go func() {
+		ticker := time.NewTicker(15 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				sse.comment("keepalive")
+			case <-keepaliveDone:
+				return
+			}
+		}
+	}()
+
+	var assembled strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for scanner.Scan() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		line := scanner.Text()
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			continue
+		}
+		var chunk geminiResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			continue
+		}
+		if len(chunk.Candidates) == 0 || len(chunk.Candidates[0].Content.Parts) == 0 {
+			continue
+		}
+		delta := chunk.Candidates[0].Content.Parts[0].Text
+		if delta == "" {
+			continue
+		}
+		assembled.WriteString(delta)
+		sse.writeEvent("delta", delta)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading gemini stream: %w", err)
+	}
+
+	final := strings.TrimSpace(assembled.String())
+	if final == "" {
+		final = "{}"
+	}
+	sse.writeEvent("done", final)
+	return nil
+}